@@ -0,0 +1,126 @@
+package protocol
+
+import (
+  "bytes"
+  "crypto/sha256"
+  "hash"
+)
+
+// MerkleConfig selects the hash function, chunk size and domain-separation
+// prefixes used to build and verify a Merkle tree. This lets callers
+// interoperate with ecosystems already committed to a specific hash --
+// Blake3 trees, Keccak-256 trees for EVM verification -- or swap hashes in
+// benchmarks, without forking the tree logic.
+type MerkleConfig struct {
+  HashFunc   func() hash.Hash
+  ChunkSize  int32
+  LeafPrefix []byte
+  NodePrefix []byte
+
+  // NumRoutines caps the number of goroutines MerkleHashWith and
+  // CreateMerkleProofWith shard leaf-hashing and the lower folds across.
+  // Zero defaults to runtime.NumCPU(). The actual shard count is rounded
+  // down to the largest power of two <= NumRoutines, and further reduced
+  // so no shard would be pure padding -- so e.g. NumRoutines: 6 runs 4
+  // goroutines, not 6. Results are identical either way; see shardCount.
+  NumRoutines int32
+}
+
+// defaultConfig reproduces the behaviour of the package-level MerkleHash,
+// CreateMerkleProof and VerifyMerkleProof functions. It copies LeafPrefix/
+// NodePrefix rather than aliasing them, so mutating the exported package
+// vars (however ill-advised) can't reach back and corrupt the prefixes
+// every default-config call relies on.
+var defaultConfig = MerkleConfig{
+  HashFunc:   sha256.New,
+  ChunkSize:  defaultChunkSize,
+  LeafPrefix: append([]byte(nil), LeafPrefix...),
+  NodePrefix: append([]byte(nil), NodePrefix...),
+}
+
+// MerkleHashWith splits `data` into cfg.ChunkSize chunks and calculates the
+// Merkle root using cfg's hash function and domain prefixes. When the tree
+// has two or more chunks, cfg.NumRoutines shards the work across
+// goroutines; see buildAllLevels.
+func MerkleHashWith(cfg MerkleConfig, data []byte) Digest {
+  chunks := Split(data, cfg.ChunkSize)
+  if len(chunks) == 0 {
+    return hashLeaf(cfg.HashFunc(), cfg.LeafPrefix, nil)
+  }
+  levels := treeLevels(len(chunks))
+  allLevels := buildAllLevels(cfg, chunks, levels)
+  return allLevels[levels][0]
+}
+
+// CreateMerkleProofWith splits `data` into cfg.ChunkSize chunks and
+// calculates a proof for the specified range using cfg's hash function and
+// domain prefixes. It reads the tree's per-level digests from
+// buildAllLevels, so the Layers it returns are identical -- including
+// their ordering -- whether or not cfg.NumRoutines caused construction to
+// run in parallel.
+func CreateMerkleProofWith(cfg MerkleConfig, data []byte, offset int32, length int32) MerkleProof {
+  chunks := Split(data, cfg.ChunkSize)
+  if len(chunks) == 0 {
+    // Mirrors MerkleHashWith: the root is the bare zero-leaf digest, so no
+    // sibling layers are needed to prove it.
+    return MerkleProof{}
+  }
+  levels := treeLevels(len(chunks))
+  allLevels := buildAllLevels(cfg, chunks, levels)
+
+  lo := offset / cfg.ChunkSize
+  hi := (offset + length - 1) / cfg.ChunkSize
+
+  layers := make([]MerkleProofLayer, 0, levels)
+  for l := 0; l < levels; l++ {
+    level := allLevels[l]
+    var layer MerkleProofLayer
+    if lo%2 == 1 {
+      sib := level[lo-1]
+      layer.left = &sib
+      lo--
+    }
+    if hi%2 == 0 {
+      sib := level[hi+1]
+      layer.right = &sib
+      hi++
+    }
+    layers = append(layers, layer)
+    lo /= 2
+    hi /= 2
+  }
+  return MerkleProof{Layers: layers}
+}
+
+// VerifyMerkleProofWith checks a proof produced by CreateMerkleProofWith
+// against the same cfg. `data` is the raw bytes of the proven range itself,
+// not the whole original input.
+func VerifyMerkleProofWith(cfg MerkleConfig, data []byte, proof MerkleProof, merkleRoot Digest) bool {
+  h := cfg.HashFunc()
+  chunks := Split(data, cfg.ChunkSize)
+  if len(chunks) == 0 {
+    // Mirrors MerkleHashWith/CreateMerkleProofWith: zero chunks means the
+    // root is the bare zero-leaf digest and carries no sibling layers.
+    return len(proof.Layers) == 0 && bytes.Equal(hashLeaf(h, cfg.LeafPrefix, nil), merkleRoot)
+  }
+  level := make([]Digest, 0, len(proof.Layers)+1)
+  for _, c := range chunks {
+    level = append(level, hashLeaf(h, cfg.LeafPrefix, c))
+  }
+
+  for _, layer := range proof.Layers {
+    merged := make([]Digest, 0, len(level)+2)
+    if layer.left != nil {
+      merged = append(merged, *layer.left)
+    }
+    merged = append(merged, level...)
+    if layer.right != nil {
+      merged = append(merged, *layer.right)
+    }
+    if len(merged)%2 != 0 {
+      return false
+    }
+    level = parentLevel(h, cfg.NodePrefix, merged)
+  }
+  return len(level) == 1 && bytes.Equal(level[0], merkleRoot)
+}
@@ -0,0 +1,23 @@
+package protocol
+
+import (
+  "bytes"
+  "testing"
+)
+
+func TestNewMerkleHasherWithUsesConfig(t *testing.T) {
+  cfg := defaultConfig
+  cfg.LeafPrefix = []byte{0xAA}
+  cfg.NodePrefix = []byte{0xBB}
+
+  data := randomData(t, 5, defaultChunkSize)
+  want := MerkleHashWith(cfg, data)
+
+  hasher := NewMerkleHasherWith(cfg)
+  if _, err := hasher.Write(data); err != nil {
+    t.Fatalf("Write: %v", err)
+  }
+  if got := Digest(hasher.Sum(nil)); !bytes.Equal(got, want) {
+    t.Errorf("got %x, want %x", got, want)
+  }
+}
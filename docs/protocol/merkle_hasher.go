@@ -0,0 +1,122 @@
+package protocol
+
+import (
+  "hash"
+)
+
+// merkleHasher computes a Merkle root incrementally, in the style of a
+// binary counter: pushDigest keeps a stack with at most one partial
+// subtree root per level, collapsing two same-level siblings into the
+// level above as soon as both are available. This lets callers hash data
+// they can only see once, such as a VM memory snapshot or file stream,
+// without ever holding the whole input in memory.
+type merkleHasher struct {
+  chunkSize  int32
+  leafPrefix []byte
+  nodePrefix []byte
+  buf        []byte
+  stack      []Digest
+  count      int64
+  h          hash.Hash
+}
+
+// NewMerkleHasher returns a hash.Hash that computes the same root as
+// MerkleHash, fed incrementally via Write instead of all at once.
+func NewMerkleHasher() hash.Hash {
+  return NewMerkleHasherWith(defaultConfig)
+}
+
+// NewMerkleHasherWith is NewMerkleHasher using cfg's hash function, chunk
+// size and domain prefixes instead of the package defaults -- e.g. to
+// stream Blake3 or Keccak-256 chunks for interop with another ecosystem's
+// tree, the same way MerkleHashWith does for whole-input hashing.
+func NewMerkleHasherWith(cfg MerkleConfig) hash.Hash {
+  if cfg.ChunkSize <= 0 {
+    panic("protocol: chunkSize must be positive")
+  }
+  return &merkleHasher{
+    chunkSize:  cfg.ChunkSize,
+    leafPrefix: cfg.LeafPrefix,
+    nodePrefix: cfg.NodePrefix,
+    h:          cfg.HashFunc(),
+  }
+}
+
+func (m *merkleHasher) Write(p []byte) (int, error) {
+  written := len(p)
+  for len(p) > 0 {
+    room := int(m.chunkSize) - len(m.buf)
+    take := room
+    if take > len(p) {
+      take = len(p)
+    }
+    m.buf = append(m.buf, p[:take]...)
+    p = p[take:]
+    if len(m.buf) == int(m.chunkSize) {
+      pushDigest(m.h, m.nodePrefix, &m.stack, hashLeaf(m.h, m.leafPrefix, m.buf))
+      m.count++
+      m.buf = m.buf[:0]
+    }
+  }
+  return written, nil
+}
+
+// pushDigest inserts d at level 0 of stack and collapses it upward like a
+// binary counter: whenever a level already holds a digest, the two fold
+// into one at the level above, freeing the lower level.
+func pushDigest(h hash.Hash, nodePrefix []byte, stack *[]Digest, d Digest) {
+  level := 0
+  for {
+    if level == len(*stack) {
+      *stack = append(*stack, nil)
+    }
+    if (*stack)[level] == nil {
+      (*stack)[level] = d
+      return
+    }
+    d = hashNode(h, nodePrefix, (*stack)[level], d)
+    (*stack)[level] = nil
+    level++
+  }
+}
+
+func (m *merkleHasher) Sum(b []byte) []byte {
+  // Work on a snapshot so Sum can be called without disturbing a hasher
+  // that's still being written to, per hash.Hash semantics.
+  stack := append([]Digest(nil), m.stack...)
+  count := m.count
+  if len(m.buf) > 0 {
+    pushDigest(m.h, m.nodePrefix, &stack, hashLeaf(m.h, m.leafPrefix, m.buf))
+    count++
+  }
+
+  if count == 0 {
+    return append(b, hashLeaf(m.h, m.leafPrefix, nil)...)
+  }
+  if count&(count-1) == 0 {
+    // exact power of two: the root already sits fully folded on the stack.
+    return append(b, stack[treeLevels(int(count))]...)
+  }
+
+  levels := treeLevels(int(count))
+  zeros := zeroHashes(m.h, m.leafPrefix, m.nodePrefix, levels)
+  node := zeros[0]
+  for level := 0; level < levels; level++ {
+    if count>>uint(level)&1 == 1 {
+      node = hashNode(m.h, m.nodePrefix, stack[level], node)
+    } else {
+      node = hashNode(m.h, m.nodePrefix, node, zeros[level])
+    }
+  }
+  return append(b, node...)
+}
+
+func (m *merkleHasher) Reset() {
+  m.buf = m.buf[:0]
+  m.stack = nil
+  m.count = 0
+}
+
+func (m *merkleHasher) Size() int { return m.h.Size() }
+
+func (m *merkleHasher) BlockSize() int { return int(m.chunkSize) }
@@ -0,0 +1,36 @@
+package protocol
+
+import (
+  "bytes"
+  "testing"
+)
+
+func TestStreamingHasherMatchesMerkleHash(t *testing.T) {
+  for _, n := range leafCounts {
+    data := randomData(t, n, defaultChunkSize)
+    want := MerkleHash(data)
+
+    hasher := NewMerkleHasher()
+    if _, err := hasher.Write(data); err != nil {
+      t.Fatalf("leaves=%d: Write: %v", n, err)
+    }
+    if got := Digest(hasher.Sum(nil)); !bytes.Equal(got, want) {
+      t.Errorf("leaves=%d: one-shot Write got %x, want %x", n, got, want)
+    }
+
+    // Fed in small, chunk-straddling pieces instead of all at once.
+    hasher = NewMerkleHasher()
+    for i := 0; i < len(data); i += 17 {
+      end := i + 17
+      if end > len(data) {
+        end = len(data)
+      }
+      if _, err := hasher.Write(data[i:end]); err != nil {
+        t.Fatalf("leaves=%d: Write: %v", n, err)
+      }
+    }
+    if got := Digest(hasher.Sum(nil)); !bytes.Equal(got, want) {
+      t.Errorf("leaves=%d: piecewise Write got %x, want %x", n, got, want)
+    }
+  }
+}
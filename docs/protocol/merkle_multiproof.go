@@ -0,0 +1,168 @@
+package protocol
+
+import (
+  "bytes"
+  "sort"
+)
+
+// Range identifies a contiguous byte range of the original input, used to
+// select one or more chunks to include in a (multi-)proof.
+type Range struct {
+  Offset int32
+  Length int32
+}
+
+// MerkleMultiProof is a compact, OpenZeppelin-style audit path covering
+// several -- possibly non-contiguous -- leaves with a single Proof/
+// ProofFlags pair, instead of one independent MerkleProof per range.
+//
+// LeafIndices records the chunk index of each entry the `leaves` argument
+// to VerifyMerkleMultiProof must supply, in the same ascending order.
+// Unlike OpenZeppelin's Solidity multiproof, our node hashing does not sort
+// the pair first and is therefore order-sensitive
+// (H(left||right) != H(right||left)), so the verifier needs the indices to
+// know which side of each pair a digest belongs on. Levels is the number
+// of tree levels the proof climbs, i.e. how many level-by-level passes the
+// verifier makes; ProofFlags is read once, in order, across those passes.
+type MerkleMultiProof struct {
+  LeafIndices []int32
+  Proof       []Digest
+  ProofFlags  []bool
+  Levels      int32
+}
+
+// rangesToIndices expands ranges into the sorted, deduplicated set of
+// covered chunk indices, clipped to [0, numLeaves).
+func rangesToIndices(ranges []Range, chunkSize int32, numLeaves int) []int {
+  set := make(map[int]bool)
+  for _, r := range ranges {
+    lo := int(r.Offset / chunkSize)
+    hi := int((r.Offset + r.Length - 1) / chunkSize)
+    for i := lo; i <= hi && i < numLeaves; i++ {
+      set[i] = true
+    }
+  }
+  indices := make([]int, 0, len(set))
+  for i := range set {
+    indices = append(indices, i)
+  }
+  sort.Ints(indices)
+  return indices
+}
+
+// CreateMerkleMultiProof splits `data` into default-sized chunks and builds
+// a MerkleMultiProof covering the chunks selected by ranges. See
+// CreateMerkleMultiProofWith.
+func CreateMerkleMultiProof(data []byte, ranges []Range) MerkleMultiProof {
+  return CreateMerkleMultiProofWith(defaultConfig, data, ranges)
+}
+
+// CreateMerkleMultiProofWith is CreateMerkleMultiProof using cfg's hash
+// function, chunk size and domain prefixes instead of the package
+// defaults. It walks the tree bottom-up, one level per pass: whenever both
+// children of a pair are already known -- supplied as leaves, or computed
+// on the previous pass -- the pair folds for free and ProofFlags records
+// true; otherwise the missing sibling is appended to Proof and ProofFlags
+// records false.
+func CreateMerkleMultiProofWith(cfg MerkleConfig, data []byte, ranges []Range) MerkleMultiProof {
+  h := cfg.HashFunc()
+  chunks := Split(data, cfg.ChunkSize)
+  levels := treeLevels(len(chunks))
+  level := leafLevel(h, cfg.LeafPrefix, chunks, levels, zeroHashes(h, cfg.LeafPrefix, cfg.NodePrefix, levels))
+
+  indices := rangesToIndices(ranges, cfg.ChunkSize, len(level))
+  leafIndices := make([]int32, len(indices))
+  for i, idx := range indices {
+    leafIndices[i] = int32(idx)
+  }
+
+  var proof []Digest
+  var flags []bool
+  known := indices
+  for l := 0; l < levels; l++ {
+    next := make([]int, 0, len(known)/2+1)
+    for i := 0; i < len(known); {
+      idx := known[i]
+      sibling := idx ^ 1
+      if i+1 < len(known) && known[i+1] == sibling {
+        flags = append(flags, true)
+        i += 2
+      } else {
+        proof = append(proof, level[sibling])
+        flags = append(flags, false)
+        i++
+      }
+      next = append(next, idx/2)
+    }
+    level = parentLevel(h, cfg.NodePrefix, level)
+    known = next
+  }
+
+  return MerkleMultiProof{LeafIndices: leafIndices, Proof: proof, ProofFlags: flags, Levels: int32(levels)}
+}
+
+// VerifyMerkleMultiProof checks a proof produced by CreateMerkleMultiProof.
+// See VerifyMerkleMultiProofWith.
+func VerifyMerkleMultiProof(leaves [][]byte, proof MerkleMultiProof, root Digest) bool {
+  return VerifyMerkleMultiProofWith(defaultConfig, leaves, proof, root)
+}
+
+// VerifyMerkleMultiProofWith checks a proof produced by
+// CreateMerkleMultiProofWith against the same cfg. `leaves` -- given in
+// ascending index order matching proof.LeafIndices -- fold, via
+// proof.Proof and proof.ProofFlags, up to root.
+func VerifyMerkleMultiProofWith(cfg MerkleConfig, leaves [][]byte, proof MerkleMultiProof, root Digest) bool {
+  if len(leaves) != len(proof.LeafIndices) || len(leaves) == 0 {
+    return false
+  }
+  h := cfg.HashFunc()
+  known := make([]int, len(proof.LeafIndices))
+  digests := make([]Digest, len(leaves))
+  for i, leaf := range leaves {
+    known[i] = int(proof.LeafIndices[i])
+    digests[i] = hashLeaf(h, cfg.LeafPrefix, leaf)
+  }
+
+  proofPos, flagPos := 0, 0
+  for l := int32(0); l < proof.Levels; l++ {
+    next := make([]int, 0, len(known)/2+1)
+    nextDigests := make([]Digest, 0, len(known)/2+1)
+    for i := 0; i < len(known); {
+      if flagPos >= len(proof.ProofFlags) {
+        return false
+      }
+      idx := known[i]
+      var left, right Digest
+      if proof.ProofFlags[flagPos] {
+        if i+1 >= len(known) || known[i+1] != idx^1 {
+          return false
+        }
+        if idx%2 == 0 {
+          left, right = digests[i], digests[i+1]
+        } else {
+          left, right = digests[i+1], digests[i]
+        }
+        i += 2
+      } else {
+        if proofPos >= len(proof.Proof) {
+          return false
+        }
+        sibling := proof.Proof[proofPos]
+        proofPos++
+        if idx%2 == 0 {
+          left, right = digests[i], sibling
+        } else {
+          left, right = sibling, digests[i]
+        }
+        i++
+      }
+      flagPos++
+      next = append(next, idx/2)
+      nextDigests = append(nextDigests, hashNode(h, cfg.NodePrefix, left, right))
+    }
+    known, digests = next, nextDigests
+  }
+
+  return proofPos == len(proof.Proof) && flagPos == len(proof.ProofFlags) &&
+    len(digests) == 1 && bytes.Equal(digests[0], root)
+}
@@ -0,0 +1,65 @@
+package protocol
+
+import "testing"
+
+func TestMerkleMultiProofRoundTrip(t *testing.T) {
+  n := 9
+  data := randomData(t, n, defaultChunkSize)
+  root := MerkleHash(data)
+  ranges := []Range{
+    {Offset: 0, Length: defaultChunkSize},
+    {Offset: defaultChunkSize * 3, Length: defaultChunkSize * 2},
+    {Offset: defaultChunkSize * 8, Length: defaultChunkSize},
+  }
+  proof := CreateMerkleMultiProof(data, ranges)
+
+  leaves := make([][]byte, len(proof.LeafIndices))
+  for i, idx := range proof.LeafIndices {
+    start := idx * defaultChunkSize
+    end := start + defaultChunkSize
+    if end > int32(len(data)) {
+      end = int32(len(data))
+    }
+    leaves[i] = data[start:end]
+  }
+  if !VerifyMerkleMultiProof(leaves, proof, root) {
+    t.Fatal("multiproof did not verify")
+  }
+
+  leaves[0] = append([]byte{}, leaves[0]...)
+  leaves[0][0] ^= 0xff
+  if VerifyMerkleMultiProof(leaves, proof, root) {
+    t.Fatal("multiproof verified against a tampered leaf")
+  }
+}
+
+func TestMerkleMultiProofRoundTripWithConfig(t *testing.T) {
+  cfg := defaultConfig
+  cfg.LeafPrefix = []byte{0xAA}
+  cfg.NodePrefix = []byte{0xBB}
+
+  n := 9
+  data := randomData(t, n, defaultChunkSize)
+  root := MerkleHashWith(cfg, data)
+  ranges := []Range{
+    {Offset: 0, Length: defaultChunkSize},
+    {Offset: defaultChunkSize * 8, Length: defaultChunkSize},
+  }
+  proof := CreateMerkleMultiProofWith(cfg, data, ranges)
+
+  leaves := make([][]byte, len(proof.LeafIndices))
+  for i, idx := range proof.LeafIndices {
+    start := idx * defaultChunkSize
+    end := start + defaultChunkSize
+    if end > int32(len(data)) {
+      end = int32(len(data))
+    }
+    leaves[i] = data[start:end]
+  }
+  if !VerifyMerkleMultiProofWith(cfg, leaves, proof, root) {
+    t.Fatal("multiproof did not verify under custom config")
+  }
+  if VerifyMerkleMultiProof(leaves, proof, root) {
+    t.Fatal("multiproof verified under the default config's prefixes")
+  }
+}
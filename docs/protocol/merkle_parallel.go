@@ -0,0 +1,114 @@
+package protocol
+
+import (
+  "runtime"
+  "sync"
+)
+
+// effectiveRoutines resolves cfg.NumRoutines to a concrete goroutine count,
+// defaulting to every available CPU when unset.
+func effectiveRoutines(cfg MerkleConfig) int {
+  if cfg.NumRoutines > 0 {
+    return int(cfg.NumRoutines)
+  }
+  return runtime.NumCPU()
+}
+
+// shardCount picks the largest power-of-two shard count that's at most
+// routines and leaves every shard at least one real (non-padding) chunk.
+// numLeaves is the padded leaf count (1<<levels); numChunks is the real
+// chunk count before zero-padding. Doubling shards halves shardLeaves
+// (numLeaves/shards), so the last shard's first padded-leaf index is
+// numLeaves-shardLeaves: once that's >= numChunks, the last shard (and
+// every shard after it, were there more) would be pure zero-padding, so
+// doubling stops one step earlier.
+func shardCount(routines, numLeaves, numChunks int) int {
+  gap := numLeaves - numChunks
+  shards := 1
+  for shards*2 <= routines && shards*2 <= numLeaves {
+    shardLeaves := numLeaves / (shards * 2)
+    if shardLeaves <= gap {
+      break
+    }
+    shards *= 2
+  }
+  return shards
+}
+
+// buildAllLevels returns the tree's digest level at every height, from the
+// padded leaves (index 0) up to the root (index levels, length 1). When
+// shardCount yields more than one shard -- any tree of two or more chunks,
+// given two or more routines -- the leaf-hashing and the lower folds are computed by one goroutine per shard -- each returning
+// its own subtree root plus its internal per-level digests -- and the
+// shards' levels are concatenated in order before the remaining folds
+// continue sequentially. The result is identical, level for level, to
+// what the purely sequential fold produces, so callers that index into it
+// (MerkleHashWith, CreateMerkleProofWith) don't need a separate code path.
+func buildAllLevels(cfg MerkleConfig, chunks []Chunk, levels int) [][]Digest {
+  shards := shardCount(effectiveRoutines(cfg), 1<<levels, len(chunks))
+
+  var allLevels [][]Digest
+  if shards > 1 {
+    allLevels = buildLeafLevelsParallel(cfg, chunks, levels, shards)
+  } else {
+    h := cfg.HashFunc()
+    zeros := zeroHashes(h, cfg.LeafPrefix, cfg.NodePrefix, levels)
+    allLevels = [][]Digest{leafLevel(h, cfg.LeafPrefix, chunks, levels, zeros)}
+  }
+
+  h := cfg.HashFunc()
+  for l := len(allLevels) - 1; l < levels; l++ {
+    allLevels = append(allLevels, parentLevel(h, cfg.NodePrefix, allLevels[l]))
+  }
+  return allLevels
+}
+
+// buildLeafLevelsParallel hashes and folds `shards` contiguous leaf ranges
+// concurrently, one goroutine per shard, up to each shard's own subtree
+// root. It returns the concatenated digest level at every height from 0
+// (leaves) up to shardLevels (one digest per shard) -- heights above that
+// no longer parallelize usefully and are left for the sequential fold in
+// buildAllLevels.
+func buildLeafLevelsParallel(cfg MerkleConfig, chunks []Chunk, levels, shards int) [][]Digest {
+  numLeaves := 1 << levels
+  shardLeaves := numLeaves / shards
+  shardLevels := treeLevels(shardLeaves)
+
+  shardResults := make([][][]Digest, shards)
+  var wg sync.WaitGroup
+  for s := 0; s < shards; s++ {
+    wg.Add(1)
+    go func(s int) {
+      defer wg.Done()
+      lo := s * shardLeaves
+      hi := lo + shardLeaves
+      if hi > len(chunks) {
+        hi = len(chunks)
+      }
+      var shardChunks []Chunk
+      if lo < hi {
+        shardChunks = chunks[lo:hi]
+      }
+
+      h := cfg.HashFunc()
+      zeros := zeroHashes(h, cfg.LeafPrefix, cfg.NodePrefix, shardLevels)
+      perLevel := make([][]Digest, 1, shardLevels+1)
+      perLevel[0] = leafLevel(h, cfg.LeafPrefix, shardChunks, shardLevels, zeros)
+      for l := 0; l < shardLevels; l++ {
+        perLevel = append(perLevel, parentLevel(h, cfg.NodePrefix, perLevel[l]))
+      }
+      shardResults[s] = perLevel
+    }(s)
+  }
+  wg.Wait()
+
+  heights := make([][]Digest, shardLevels+1)
+  for height := 0; height <= shardLevels; height++ {
+    full := make([]Digest, 0, numLeaves>>uint(height))
+    for s := 0; s < shards; s++ {
+      full = append(full, shardResults[s][height]...)
+    }
+    heights[height] = full
+  }
+  return heights
+}
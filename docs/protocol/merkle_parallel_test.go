@@ -0,0 +1,69 @@
+package protocol
+
+import (
+  "bytes"
+  "testing"
+)
+
+func TestParallelMatchesSequentialLayers(t *testing.T) {
+  for _, n := range []int{5, 8, 17, 64} {
+    data := randomData(t, n, defaultChunkSize)
+
+    seqCfg := defaultConfig
+    seqCfg.NumRoutines = 1
+    parCfg := defaultConfig
+    parCfg.NumRoutines = 8
+
+    seqRoot := MerkleHashWith(seqCfg, data)
+    parRoot := MerkleHashWith(parCfg, data)
+    if !bytes.Equal(seqRoot, parRoot) {
+      t.Fatalf("leaves=%d: sequential root %x != parallel root %x", n, seqRoot, parRoot)
+    }
+
+    seqProof := CreateMerkleProofWith(seqCfg, data, 0, defaultChunkSize)
+    parProof := CreateMerkleProofWith(parCfg, data, 0, defaultChunkSize)
+    if len(seqProof.Layers) != len(parProof.Layers) {
+      t.Fatalf("leaves=%d: layer count differs: %d vs %d", n, len(seqProof.Layers), len(parProof.Layers))
+    }
+    for i := range seqProof.Layers {
+      if !digestPtrEqual(seqProof.Layers[i].left, parProof.Layers[i].left) ||
+        !digestPtrEqual(seqProof.Layers[i].right, parProof.Layers[i].right) {
+        t.Fatalf("leaves=%d: layer %d differs between sequential and parallel", n, i)
+      }
+    }
+  }
+}
+
+func digestPtrEqual(a, b *Digest) bool {
+  if (a == nil) != (b == nil) {
+    return false
+  }
+  return a == nil || bytes.Equal(*a, *b)
+}
+
+// benchmarkInputSize is large enough (2048 default-size chunks) for
+// buildAllLevels' sharding to have real work to split across goroutines.
+const benchmarkInputSize = 8 << 20 // 8MiB
+
+func benchmarkMerkleHash(b *testing.B, cfg MerkleConfig) {
+  data := randomBytes(benchmarkInputSize/int(defaultChunkSize), defaultChunkSize)
+  b.SetBytes(int64(len(data)))
+  b.ResetTimer()
+  for i := 0; i < b.N; i++ {
+    MerkleHashWith(cfg, data)
+  }
+}
+
+// BenchmarkMerkleHashSequential and BenchmarkMerkleHashParallel hash the
+// same multi-MB input so `go test -bench MerkleHash -benchtime=3x` shows
+// the speedup buildAllLevels' sharding delivers on a multi-core machine.
+func BenchmarkMerkleHashSequential(b *testing.B) {
+  cfg := defaultConfig
+  cfg.NumRoutines = 1
+  benchmarkMerkleHash(b, cfg)
+}
+
+func BenchmarkMerkleHashParallel(b *testing.B) {
+  cfg := defaultConfig // NumRoutines 0 defaults to runtime.NumCPU()
+  benchmarkMerkleHash(b, cfg)
+}
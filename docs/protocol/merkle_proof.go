@@ -1,5 +1,31 @@
 package protocol
 
+import (
+  "bytes"
+  "crypto/sha256"
+  "hash"
+)
+
+// RFC 6962 domain separation: leaves and interior nodes are hashed under
+// disjoint prefixes so a chosen pair of leaves can never be replayed as an
+// interior node (the classic Merkle second-preimage attack). Exported so
+// external verifiers (on-chain contracts, Wasm modules checking chunk
+// ranges) can reproduce the same bytes. These are also the prefixes used
+// by defaultConfig; MerkleConfig.LeafPrefix/NodePrefix let callers choose
+// different ones.
+var (
+  LeafPrefix = []byte{0x00}
+  NodePrefix = []byte{0x01}
+)
+
+const defaultChunkSize int32 = 4096
+
+// Digest is the output of the Merkle hash function.
+type Digest []byte
+
+// Chunk is a fixed-size slice of the original data being hashed.
+type Chunk []byte
+
 type MerkleProofLayer struct {
   left  *Digest
   right *Digest
@@ -10,13 +36,188 @@ type MerkleProof struct {
 }
 
 // splits the byte sequence into chunks of specific size
-func Split(data []byte, chunkSize int32) []Chunk { panic("") }
+func Split(data []byte, chunkSize int32) []Chunk {
+  if chunkSize <= 0 {
+    panic("protocol: chunkSize must be positive")
+  }
+  chunks := make([]Chunk, 0, (int32(len(data))+chunkSize-1)/chunkSize)
+  for offset := int32(0); offset < int32(len(data)); offset += chunkSize {
+    end := offset + chunkSize
+    if end > int32(len(data)) {
+      end = int32(len(data))
+    }
+    chunks = append(chunks, Chunk(data[offset:end]))
+  }
+  return chunks
+}
+
+// hashLeaf hashes a chunk as H(leafPrefix || chunk).
+func hashLeaf(h hash.Hash, leafPrefix []byte, chunk []byte) Digest {
+  h.Reset()
+  h.Write(leafPrefix)
+  h.Write(chunk)
+  return h.Sum(nil)
+}
+
+// hashNode hashes a pair of children as H(nodePrefix || left || right).
+func hashNode(h hash.Hash, nodePrefix []byte, left, right Digest) Digest {
+  h.Reset()
+  h.Write(nodePrefix)
+  h.Write(left)
+  h.Write(right)
+  return h.Sum(nil)
+}
+
+// zeroHashes returns the padding digest for each level above the leaves, so
+// a chunk count that isn't a power of two still folds into a single root
+// without duplicating real leaves.
+func zeroHashes(h hash.Hash, leafPrefix, nodePrefix []byte, levels int) []Digest {
+  zeros := make([]Digest, levels+1)
+  zeros[0] = hashLeaf(h, leafPrefix, nil)
+  for i := 1; i <= levels; i++ {
+    zeros[i] = hashNode(h, nodePrefix, zeros[i-1], zeros[i-1])
+  }
+  return zeros
+}
+
+// treeLevels returns the number of levels needed to fold numLeaves leaves,
+// i.e. the smallest levels such that 1<<levels >= numLeaves.
+func treeLevels(numLeaves int) int {
+  levels := 0
+  for (1 << levels) < numLeaves {
+    levels++
+  }
+  return levels
+}
+
+// leafLevel hashes chunks into leaf digests and pads the result to 1<<levels
+// with the zero-leaf digest so it can be folded level by level.
+func leafLevel(h hash.Hash, leafPrefix []byte, chunks []Chunk, levels int, zeros []Digest) []Digest {
+  level := make([]Digest, 1<<levels)
+  for i, c := range chunks {
+    level[i] = hashLeaf(h, leafPrefix, c)
+  }
+  for i := len(chunks); i < len(level); i++ {
+    level[i] = zeros[0]
+  }
+  return level
+}
+
+// parentLevel folds a level of digests into the level above it.
+func parentLevel(h hash.Hash, nodePrefix []byte, level []Digest) []Digest {
+  parents := make([]Digest, len(level)/2)
+  for i := range parents {
+    parents[i] = hashNode(h, nodePrefix, level[2*i], level[2*i+1])
+  }
+  return parents
+}
 
 // splits `data` in default-sized chunks and calculates Merkle root out of them
-func MerkleHash(data []byte) Digest { panic("") }
+func MerkleHash(data []byte) Digest {
+  return MerkleHashWith(defaultConfig, data)
+}
+
+// MerkleHashLegacy reproduces the pre-RFC-6962 root, hashing leaves and
+// interior nodes without domain separation. It exists only so roots
+// computed before the migration can still be checked during the
+// transition; new roots should always come from MerkleHash.
+func MerkleHashLegacy(data []byte) Digest {
+  h := sha256.New()
+  chunks := Split(data, defaultChunkSize)
+  hashLeafLegacy := func(chunk []byte) Digest {
+    h.Reset()
+    h.Write(chunk)
+    return h.Sum(nil)
+  }
+  hashNodeLegacy := func(left, right Digest) Digest {
+    h.Reset()
+    h.Write(left)
+    h.Write(right)
+    return h.Sum(nil)
+  }
+  if len(chunks) == 0 {
+    return hashLeafLegacy(nil)
+  }
+  levels := treeLevels(len(chunks))
+  level := make([]Digest, 1<<levels)
+  zeros := make([]Digest, levels+1)
+  zeros[0] = hashLeafLegacy(nil)
+  for i := 1; i <= levels; i++ {
+    zeros[i] = hashNodeLegacy(zeros[i-1], zeros[i-1])
+  }
+  for i, c := range chunks {
+    level[i] = hashLeafLegacy(c)
+  }
+  for i := len(chunks); i < len(level); i++ {
+    level[i] = zeros[0]
+  }
+  for l := 0; l < levels; l++ {
+    next := make([]Digest, len(level)/2)
+    for i := range next {
+      next[i] = hashNodeLegacy(level[2*i], level[2*i+1])
+    }
+    level = next
+  }
+  return level[0]
+}
 
-// splits `data` in default-sized chunks and calculates proof for the specified range
-func CreateMerkleProof(data []byte, offset int32, length int32) MerkleProof { panic("") }
+// VerifyMerkleProofLegacy checks a proof against a pre-RFC-6962 root (see
+// MerkleHashLegacy): leaves and interior nodes folded without domain
+// separation. It exists so a proof issued before the migration can still
+// be checked against its legacy root while both are in flight; new proofs
+// should always be verified with VerifyMerkleProof.
+func VerifyMerkleProofLegacy(data []byte, proof MerkleProof, merkleRoot Digest) bool {
+  h := sha256.New()
+  hashLeafLegacy := func(chunk []byte) Digest {
+    h.Reset()
+    h.Write(chunk)
+    return h.Sum(nil)
+  }
+  hashNodeLegacy := func(left, right Digest) Digest {
+    h.Reset()
+    h.Write(left)
+    h.Write(right)
+    return h.Sum(nil)
+  }
 
-// checks merkle proof for the range of default-sized chunks
-func VerifyMerkleProof(data []byte, proof MerkleProof, merkleRoot Digest) bool { panic("") }
+  level := make([]Digest, 0, len(proof.Layers)+1)
+  for _, c := range Split(data, defaultChunkSize) {
+    level = append(level, hashLeafLegacy(c))
+  }
+
+  for _, layer := range proof.Layers {
+    merged := make([]Digest, 0, len(level)+2)
+    if layer.left != nil {
+      merged = append(merged, *layer.left)
+    }
+    merged = append(merged, level...)
+    if layer.right != nil {
+      merged = append(merged, *layer.right)
+    }
+    if len(merged)%2 != 0 {
+      return false
+    }
+    next := make([]Digest, len(merged)/2)
+    for i := range next {
+      next[i] = hashNodeLegacy(merged[2*i], merged[2*i+1])
+    }
+    level = next
+  }
+  return len(level) == 1 && bytes.Equal(level[0], merkleRoot)
+}
+
+// splits `data` in default-sized chunks and calculates proof for the
+// specified range. The proof carries, for each level of the tree, the
+// sibling digest(s) needed to extend the range's own hash up to the root:
+// a left sibling when the range's lower bound isn't aligned to that level,
+// a right sibling when the upper bound isn't.
+func CreateMerkleProof(data []byte, offset int32, length int32) MerkleProof {
+  return CreateMerkleProofWith(defaultConfig, data, offset, length)
+}
+
+// checks merkle proof for the range of default-sized chunks. `data` is the
+// raw bytes of the proven range itself (the bytes passed as offset/length
+// to CreateMerkleProof), not the whole original input.
+func VerifyMerkleProof(data []byte, proof MerkleProof, merkleRoot Digest) bool {
+  return VerifyMerkleProofWith(defaultConfig, data, proof, merkleRoot)
+}
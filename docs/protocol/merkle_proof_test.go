@@ -0,0 +1,146 @@
+package protocol
+
+import (
+  "bytes"
+  "crypto/sha256"
+  "math/rand"
+  "testing"
+)
+
+// leafCounts covers both sides of every power-of-two boundary, since the
+// zero-padding fold (zeroHashes/leafLevel) and the streaming hasher's
+// bit-counter fold (merkleHasher.Sum) take different paths for an exact
+// power of two vs. everything else.
+var leafCounts = []int{0, 1, 2, 3, 4, 5, 7, 8, 9, 16, 17, 31, 32, 33}
+
+// randomBytes returns numChunks*chunkSize pseudo-random bytes, seeded by
+// numChunks so a given size is reproducible across a test run.
+func randomBytes(numChunks int, chunkSize int32) []byte {
+  data := make([]byte, int(chunkSize)*numChunks)
+  rand.New(rand.NewSource(int64(numChunks))).Read(data)
+  return data
+}
+
+func randomData(t *testing.T, numChunks int, chunkSize int32) []byte {
+  t.Helper()
+  return randomBytes(numChunks, chunkSize)
+}
+
+func max(a, b int) int {
+  if a > b {
+    return a
+  }
+  return b
+}
+
+func TestHashLeafAndHashNodeAreDomainSeparated(t *testing.T) {
+  h := sha256.New()
+  left := hashLeaf(h, LeafPrefix, []byte("left"))
+  right := hashLeaf(h, LeafPrefix, []byte("right"))
+  node := hashNode(h, NodePrefix, left, right)
+
+  // The classic second-preimage attack: replaying a node's own children as
+  // a single leaf must not reproduce the node's digest.
+  forged := hashLeaf(h, LeafPrefix, append(append([]byte{}, left...), right...))
+  if bytes.Equal(node, forged) {
+    t.Fatal("hashNode and hashLeaf collided across domains")
+  }
+}
+
+func TestMerkleProofRoundTrip(t *testing.T) {
+  for _, n := range leafCounts {
+    data := randomData(t, n, defaultChunkSize)
+    root := MerkleHash(data)
+    for _, tc := range []struct {
+      name         string
+      offset, size int32
+    }{
+      {"whole", 0, int32(len(data))},
+      {"firstChunk", 0, defaultChunkSize},
+      {"lastChunk", defaultChunkSize * int32(max(n-1, 0)), defaultChunkSize},
+      {"middleRange", defaultChunkSize, defaultChunkSize * 2},
+    } {
+      if tc.offset+tc.size > int32(len(data)) {
+        continue
+      }
+      proof := CreateMerkleProof(data, tc.offset, tc.size)
+      if !VerifyMerkleProof(data[tc.offset:tc.offset+tc.size], proof, root) {
+        t.Errorf("leaves=%d case=%s: proof did not verify", n, tc.name)
+      }
+      tamperedRoot := append(Digest{}, root...)
+      tamperedRoot[0] ^= 0xff
+      if VerifyMerkleProof(data[tc.offset:tc.offset+tc.size], proof, tamperedRoot) {
+        t.Errorf("leaves=%d case=%s: proof verified against a tampered root", n, tc.name)
+      }
+    }
+  }
+}
+
+func TestMerkleHashLegacyAndVerifyMerkleProofLegacy(t *testing.T) {
+  data := randomData(t, 9, defaultChunkSize)
+  root := MerkleHashLegacy(data)
+  if bytes.Equal(root, MerkleHash(data)) {
+    t.Fatal("legacy and RFC-6962 roots collided; domain separation isn't doing anything")
+  }
+
+  proof := legacyProof(t, data, 0, defaultChunkSize)
+  if !VerifyMerkleProofLegacy(data[:defaultChunkSize], proof, root) {
+    t.Fatal("legacy proof did not verify against legacy root")
+  }
+  if VerifyMerkleProof(data[:defaultChunkSize], proof, root) {
+    t.Fatal("legacy proof verified under the RFC-6962 verifier")
+  }
+}
+
+// legacyProof builds a proof the way CreateMerkleProof would, but over
+// MerkleHashLegacy's unprefixed leaves/nodes, for VerifyMerkleProofLegacy
+// to check. There's no CreateMerkleProofLegacy -- migration only needs to
+// verify proofs issued before the cutover, never mint new legacy ones.
+func legacyProof(t *testing.T, data []byte, offset, length int32) MerkleProof {
+  t.Helper()
+  h := sha256.New()
+  chunks := Split(data, defaultChunkSize)
+  levels := treeLevels(len(chunks))
+
+  level := make([]Digest, 1<<levels)
+  for i, c := range chunks {
+    h.Reset()
+    h.Write(c)
+    level[i] = h.Sum(nil)
+  }
+  h.Reset()
+  zero := h.Sum(nil)
+  for i := len(chunks); i < len(level); i++ {
+    level[i] = zero
+  }
+
+  lo := offset / defaultChunkSize
+  hi := (offset + length - 1) / defaultChunkSize
+  layers := make([]MerkleProofLayer, 0, levels)
+  for l := 0; l < levels; l++ {
+    var layer MerkleProofLayer
+    if lo%2 == 1 {
+      sib := level[lo-1]
+      layer.left = &sib
+      lo--
+    }
+    if hi%2 == 0 {
+      sib := level[hi+1]
+      layer.right = &sib
+      hi++
+    }
+    layers = append(layers, layer)
+    lo /= 2
+    hi /= 2
+
+    next := make([]Digest, len(level)/2)
+    for i := range next {
+      h.Reset()
+      h.Write(level[2*i])
+      h.Write(level[2*i+1])
+      next[i] = h.Sum(nil)
+    }
+    level = next
+  }
+  return MerkleProof{Layers: layers}
+}
@@ -0,0 +1,79 @@
+package protocol
+
+// MerkleTree caches a tree's interior digests level by level (levels[0] is
+// the padded leaf level, levels[len-1] the single-digest root) so a single
+// chunk -- e.g. a VM memory page -- can be rewritten and re-rooted by
+// touching only the O(log N) digests on its path, instead of re-hashing
+// the whole input.
+type MerkleTree struct {
+  cfg    MerkleConfig
+  levels [][]Digest
+}
+
+// NewMerkleTree builds a MerkleTree over `data` using the package-default
+// hash, chunk size and prefixes.
+func NewMerkleTree(data []byte) *MerkleTree {
+  return NewMerkleTreeWith(defaultConfig, data)
+}
+
+// NewMerkleTreeWith builds a MerkleTree over `data` using cfg.
+func NewMerkleTreeWith(cfg MerkleConfig, data []byte) *MerkleTree {
+  chunks := Split(data, cfg.ChunkSize)
+  levels := treeLevels(len(chunks))
+  return &MerkleTree{cfg: cfg, levels: buildAllLevels(cfg, chunks, levels)}
+}
+
+// Root returns the tree's current Merkle root.
+func (t *MerkleTree) Root() Digest {
+  return t.levels[len(t.levels)-1][0]
+}
+
+// UpdateLeaf replaces the chunk at chunkIndex with newChunk and recomputes
+// only the digests on its path to the root, returning the new root and the
+// delta proof: the unchanged siblings along that path, which a remote
+// verifier holding only the old root can replay against the old and new
+// chunk to confirm both.
+func (t *MerkleTree) UpdateLeaf(chunkIndex int32, newChunk []byte) (Digest, MerkleProof) {
+  idx := int(chunkIndex)
+  if idx < 0 || idx >= len(t.levels[0]) {
+    panic("protocol: chunk index out of range")
+  }
+
+  h := t.cfg.HashFunc()
+  d := hashLeaf(h, t.cfg.LeafPrefix, newChunk)
+  t.levels[0][idx] = d
+
+  layers := make([]MerkleProofLayer, 0, len(t.levels)-1)
+  for l := 0; l < len(t.levels)-1; l++ {
+    level := t.levels[l]
+    var layer MerkleProofLayer
+    if idx%2 == 0 {
+      sib := level[idx+1]
+      layer.right = &sib
+      d = hashNode(h, t.cfg.NodePrefix, d, sib)
+    } else {
+      sib := level[idx-1]
+      layer.left = &sib
+      d = hashNode(h, t.cfg.NodePrefix, sib, d)
+    }
+    layers = append(layers, layer)
+    idx /= 2
+    t.levels[l+1][idx] = d
+  }
+  return d, MerkleProof{Layers: layers}
+}
+
+// UpdateLeaf returns the root data would have with the chunk at chunkIndex
+// replaced by newChunk, and the delta proof for it (see
+// MerkleTree.UpdateLeaf); data itself is not modified. Callers
+// updating the same data repeatedly should build a MerkleTree once and
+// call its UpdateLeaf instead, so the O(log N) saving isn't lost to
+// re-building the cache on every call.
+func UpdateLeaf(data []byte, chunkIndex int32, newChunk []byte) (Digest, MerkleProof) {
+  return UpdateLeafWith(defaultConfig, data, chunkIndex, newChunk)
+}
+
+// UpdateLeafWith is UpdateLeaf using cfg instead of the package defaults.
+func UpdateLeafWith(cfg MerkleConfig, data []byte, chunkIndex int32, newChunk []byte) (Digest, MerkleProof) {
+  return NewMerkleTreeWith(cfg, data).UpdateLeaf(chunkIndex, newChunk)
+}
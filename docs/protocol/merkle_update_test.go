@@ -0,0 +1,28 @@
+package protocol
+
+import (
+  "bytes"
+  "testing"
+)
+
+func TestUpdateLeafMatchesRebuild(t *testing.T) {
+  n := 9
+  data := randomData(t, n, defaultChunkSize)
+  tree := NewMerkleTree(data)
+
+  newChunk := bytes.Repeat([]byte{0x42}, int(defaultChunkSize))
+  newRoot, proof := tree.UpdateLeaf(3, newChunk)
+
+  rebuilt := append([]byte{}, data...)
+  copy(rebuilt[3*defaultChunkSize:4*defaultChunkSize], newChunk)
+  want := MerkleHash(rebuilt)
+  if !bytes.Equal(newRoot, want) {
+    t.Fatalf("UpdateLeaf root %x != rebuilt root %x", newRoot, want)
+  }
+  if !bytes.Equal(tree.Root(), want) {
+    t.Fatalf("tree.Root() %x != rebuilt root %x", tree.Root(), want)
+  }
+  if !VerifyMerkleProof(newChunk, proof, newRoot) {
+    t.Fatal("delta proof did not verify the new chunk against the new root")
+  }
+}